@@ -1,6 +1,12 @@
 package telebot
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,6 +30,8 @@ type Poller interface {
 //
 // For heavy middleware, use increased capacity.
 //
+// For fan-out to several independent, runtime-adjustable
+// subscribers instead of a single filter, see Router.
 type MiddlewarePoller struct {
 	Poller Poller
 	filter func(*Update) bool
@@ -64,31 +72,561 @@ func (p *MiddlewarePoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
 	}
 }
 
+// OffsetStore lets a LongPoller persist the last processed update
+// ID across restarts, so it can resume from where it left off
+// instead of replaying or skipping updates after a crash.
+type OffsetStore interface {
+	// Load returns the last saved offset, or 0 if none was saved yet.
+	Load() (int, error)
+
+	// Save persists the given offset.
+	Save(int) error
+}
+
+// MemoryOffsetStore is an OffsetStore that keeps the offset in
+// memory only. It's the default when no Store is configured and
+// is useful in tests, but doesn't survive process restarts.
+type MemoryOffsetStore struct {
+	mu     sync.Mutex
+	offset int
+}
+
+// Load implements OffsetStore.
+func (s *MemoryOffsetStore) Load() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, nil
+}
+
+// Save implements OffsetStore.
+func (s *MemoryOffsetStore) Save(offset int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = offset
+	return nil
+}
+
+// FileOffsetStore is an OffsetStore that persists the offset as
+// plain text in a file, so it survives process restarts.
+type FileOffsetStore struct {
+	Path string
+}
+
+// Load implements OffsetStore.
+func (s *FileOffsetStore) Load() (int, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "read offset file")
+	}
+
+	offset, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, errors.Wrap(err, "parse offset file")
+	}
+	return offset, nil
+}
+
+// Save implements OffsetStore.
+func (s *FileOffsetStore) Save(offset int) error {
+	err := os.WriteFile(s.Path, []byte(strconv.Itoa(offset)), 0644)
+	return errors.Wrap(err, "write offset file")
+}
+
 // LongPoller is a classic LongPoller with timeout.
 type LongPoller struct {
 	Timeout time.Duration
+
+	// AllowedUpdates, if non-empty, is passed through to getUpdates
+	// so Telegram only sends the listed update kinds.
+	AllowedUpdates []string
+
+	// Limit caps the number of updates returned per getUpdates call.
+	// Default: 100 (Telegram's own default).
+	Limit int
+
+	// Store, if set, persists latestUpd across restarts. Default:
+	// an in-memory store, which does not survive restarts.
+	Store OffsetStore
+
+	// InitialBackoff is the delay before the first retry after a
+	// failed getUpdates call. Default: 500ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries. Default: 30s.
+	MaxBackoff time.Duration
+
+	// BackoffFactor multiplies the delay after each consecutive
+	// failure, until MaxBackoff is reached. Default: 2.
+	BackoffFactor float64
+
+	// Errors, if set, receives getUpdates errors in addition to
+	// them being logged via b.debug. Sends are non-blocking, so a
+	// slow or unread channel never stalls polling; an error the
+	// channel is not ready to take is dropped.
+	Errors chan<- error
+}
+
+// backoffDelay picks how long to wait before the next retry. It
+// honors Telegram's retry_after on a 429 (FloodError) and falls
+// back to the current backoff otherwise.
+func backoffDelay(err error, backoff time.Duration) time.Duration {
+	if fe, ok := errors.Cause(err).(FloodError); ok && fe.RetryAfter > 0 {
+		return time.Duration(fe.RetryAfter) * time.Second
+	}
+	return backoff
+}
+
+// nextBackoff grows the current backoff by factor, capped at max.
+func nextBackoff(backoff time.Duration, factor float64, max time.Duration) time.Duration {
+	backoff = time.Duration(float64(backoff) * factor)
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
 }
 
 // Poll does long polling.
 func (p *LongPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
-	var latestUpd int
+	store := p.Store
+	if store == nil {
+		store = &MemoryOffsetStore{}
+	}
+
+	latestUpd, err := store.Load()
+	if err != nil {
+		b.debug(errors.Wrap(err, "OffsetStore.Load() failed"))
+	}
+
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	factor := p.BackoffFactor
+	if factor <= 1 {
+		factor = 2
+	}
+	backoff := initial
 
 	for {
 		select {
 		case <-stop:
 			return
 		default:
-			updates, err := b.getUpdates(latestUpd+1, p.Timeout)
+			updates, err := b.getUpdates(latestUpd+1, p.Limit, p.Timeout, p.AllowedUpdates)
 
 			if err != nil {
 				b.debug(errors.Wrap(err, "getUpdates() failed"))
+				if p.Errors != nil {
+					select {
+					case p.Errors <- err:
+					default:
+					}
+				}
+
+				delay := backoffDelay(err, backoff)
+
+				select {
+				case <-stop:
+					return
+				case <-time.After(delay):
+				}
+
+				backoff = nextBackoff(backoff, factor, max)
 				continue
 			}
 
+			backoff = initial
+
 			for _, update := range updates {
 				latestUpd = update.ID
 				dest <- update
 			}
+
+			if len(updates) > 0 {
+				if err := store.Save(latestUpd); err != nil {
+					b.debug(errors.Wrap(err, "OffsetStore.Save() failed"))
+				}
+			}
+		}
+	}
+}
+
+// WebhookPoller is a poller that runs an HTTP(S) server and
+// receives Updates pushed by Telegram via the Bot API webhook
+// mechanism, instead of polling getUpdates in a loop.
+//
+// On Poll start it registers itself with Telegram through
+// setWebhook and on stop it unregisters through deleteWebhook,
+// so callers don't have to manage that by hand.
+type WebhookPoller struct {
+	// Listen is the TCP address the server listens on, e.g. ":8443".
+	Listen string
+
+	// TLSCert and TLSKey, if both set, make the server serve HTTPS
+	// directly via ListenAndServeTLS. Leave both empty when TLS is
+	// terminated upstream (e.g. behind a reverse proxy).
+	TLSCert, TLSKey string
+
+	// URL is the public HTTPS endpoint registered with Telegram,
+	// e.g. "https://example.com/bot".
+	URL string
+
+	// Path is the path updates are served on. Default: "/".
+	Path string
+
+	// SecretToken, if set, is sent to Telegram on setWebhook and
+	// verified against the X-Telegram-Bot-Api-Secret-Token header
+	// on every incoming request.
+	SecretToken string
+
+	server *http.Server
+}
+
+// Poll implements Poller. It serves the webhook until stop is
+// closed, then shuts the server down and deletes the webhook.
+func (p *WebhookPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	if err := p.setWebhook(b); err != nil {
+		b.debug(errors.Wrap(err, "setWebhook() failed"))
+		return
+	}
+
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+
+	// done is closed once Poll starts shutting down, so a handler
+	// blocked sending to dest (because the bot's update loop has
+	// stalled) gives up instead of piling up goroutines forever.
+	done := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if p.SecretToken != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != p.SecretToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var upd Update
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			b.debug(errors.Wrap(err, "webhook: decode update failed"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- upd:
+			w.WriteHeader(http.StatusOK)
+		case <-done:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	p.server = &http.Server{Addr: p.Listen, Handler: mux}
+
+	// serveErr carries a bind/TLS failure back to Poll so it can
+	// stop and clean up instead of waiting on <-stop forever with
+	// the webhook registered at Telegram but nothing listening.
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if p.TLSCert != "" && p.TLSKey != "" {
+			err = p.server.ListenAndServeTLS(p.TLSCert, p.TLSKey)
+		} else {
+			err = p.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case <-stop:
+	case err := <-serveErr:
+		b.debug(errors.Wrap(err, "webhook: server failed"))
+	}
+	close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.server.Shutdown(ctx); err != nil {
+		b.debug(errors.Wrap(err, "webhook: server shutdown failed"))
+	}
+
+	if err := p.deleteWebhook(b); err != nil {
+		b.debug(errors.Wrap(err, "deleteWebhook() failed"))
+	}
+}
+
+func (p *WebhookPoller) setWebhook(b *Bot) error {
+	params := map[string]string{"url": p.URL}
+	if p.SecretToken != "" {
+		params["secret_token"] = p.SecretToken
+	}
+	_, err := b.Raw("setWebhook", params)
+	return err
+}
+
+func (p *WebhookPoller) deleteWebhook(b *Bot) error {
+	_, err := b.Raw("deleteWebhook", map[string]string{})
+	return err
+}
+
+// FanOutMetrics is an optional observability hook for FanOutPoller.
+type FanOutMetrics interface {
+	// QueueDepth reports how many updates are currently buffered
+	// for the given worker.
+	QueueDepth(worker, depth int)
+
+	// Dropped is called when an update for the given worker is
+	// dropped because its buffer was full.
+	Dropped(worker int)
+}
+
+// FanOutPoller wraps another Poller and fans its updates out to a
+// fixed pool of worker goroutines, so slow per-update handling
+// (DB calls, outbound HTTP, ...) runs concurrently without
+// reordering messages that belong to the same chat: updates for
+// a given chat ID are always routed to the same worker.
+//
+// A Poller only has a single dest channel to hand updates to, and
+// that channel is drained by one serial loop in Bot.Start, so
+// forwarding fanned-out updates back into dest would reconverge
+// them onto that one consumer and buy nothing. Instead, each
+// worker calls b.ProcessUpdate directly, which is the same
+// dispatch Bot.Start itself uses, so the concurrency is real: up
+// to Workers updates are being processed at once.
+//
+// Because each worker's queue is bounded, a worker that falls
+// behind drops updates once its buffer fills rather than
+// blocking the others or growing without limit. Set Metrics to
+// find out when that happens - a production bot should treat
+// Dropped as an alert, not a log line: it means updates for some
+// chat are being silently lost.
+type FanOutPoller struct {
+	Poller Poller
+
+	// Workers is the number of worker goroutines. Default: 1.
+	Workers int
+
+	// Buffer is the per-worker channel capacity. Updates that
+	// arrive once a worker's buffer is full are dropped rather
+	// than blocking the other workers. Default: 64.
+	Buffer int
+
+	// Metrics, if set, is notified of queue depth and drops. Wire
+	// it up: the default behavior on overflow is to drop updates.
+	Metrics FanOutMetrics
+}
+
+// Poll implements Poller.
+func (p *FanOutPoller) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	buffer := p.Buffer
+	if buffer < 1 {
+		buffer = 64
+	}
+
+	queues := make([]chan Update, workers)
+	for i := range queues {
+		queues[i] = make(chan Update, buffer)
+	}
+
+	var wg sync.WaitGroup
+	for i := range queues {
+		wg.Add(1)
+		go func(q chan Update) {
+			defer wg.Done()
+			for upd := range q {
+				b.ProcessUpdate(upd)
+			}
+		}(queues[i])
+	}
+
+	upstream := make(chan Update, buffer)
+	stop2 := make(chan struct{})
+	go p.Poller.Poll(b, upstream, stop2)
+
+loop:
+	for {
+		select {
+		case <-stop:
+			close(stop2)
+			break loop
+		case upd := <-upstream:
+			p.dispatch(queues, workers, upd)
+		}
+	}
+
+	// Flush whatever the upstream Poller had already buffered
+	// before stop2 was closed, so it isn't silently discarded.
+drain:
+	for {
+		select {
+		case upd := <-upstream:
+			p.dispatch(queues, workers, upd)
+		default:
+			break drain
+		}
+	}
+
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+}
+
+// dispatch routes upd to its worker's queue, dropping it and
+// reporting p.Metrics if that queue is full. Kept separate from
+// Poll so the routing/overflow behavior can be tested without a
+// live Bot or upstream Poller.
+func (p *FanOutPoller) dispatch(queues []chan Update, workers int, upd Update) {
+	i := workerFor(chatID(upd), workers)
+
+	select {
+	case queues[i] <- upd:
+	default:
+		if p.Metrics != nil {
+			p.Metrics.Dropped(i)
+		}
+	}
+	if p.Metrics != nil {
+		p.Metrics.QueueDepth(i, len(queues[i]))
+	}
+}
+
+// workerFor maps a chat ID onto one of n workers. Group and
+// channel chat IDs are negative, so the result is normalized to
+// a non-negative index.
+func workerFor(id int64, n int) int {
+	idx := id % int64(n)
+	if idx < 0 {
+		idx = -idx
+	}
+	return int(idx)
+}
+
+// chatID extracts the chat an Update belongs to, for the update
+// kinds that carry one. Returns 0 if none can be determined.
+func chatID(u Update) int64 {
+	switch {
+	case u.Message != nil:
+		return u.Message.Chat.ID
+	case u.EditedMessage != nil:
+		return u.EditedMessage.Chat.ID
+	case u.ChannelPost != nil:
+		return u.ChannelPost.Chat.ID
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost.Chat.ID
+	case u.Callback != nil && u.Callback.Message != nil:
+		return u.Callback.Message.Chat.ID
+	default:
+		return 0
+	}
+}
+
+// Router is a generalization of MiddlewarePoller that fans a
+// single upstream Poller out to any number of topic-filtered
+// subscribers, in addition to relaying every update downstream
+// as usual. Unlike MiddlewarePoller, subscribers can come and go
+// at runtime, and a slow or full subscriber only drops its own
+// updates instead of blocking the others.
+type Router struct {
+	Poller Poller
+
+	// Capacity sets the buffer size for the upstream channel and
+	// for every subscriber channel. Default: 1.
+	Capacity int
+
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]*routerSub
+}
+
+type routerSub struct {
+	filter func(*Update) bool
+	ch     chan Update
+}
+
+// NewRouter constructs a Router wrapping p.
+func NewRouter(p Poller) *Router {
+	return &Router{Poller: p, subs: make(map[int]*routerSub)}
+}
+
+// Subscribe registers filter and returns a channel delivering
+// every Update for which filter returns true, along with a
+// cancel func that unsubscribes and closes the channel. Cancel
+// is safe to call more than once.
+func (r *Router) Subscribe(filter func(*Update) bool) (<-chan Update, func()) {
+	cap := r.Capacity
+	if cap < 1 {
+		cap = 1
+	}
+
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	sub := &routerSub{filter: filter, ch: make(chan Update, cap)}
+	r.subs[id] = sub
+	r.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.subs, id)
+			r.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Poll implements Poller: it relays every update from the
+// wrapped Poller to dest, and additionally dispatches it to any
+// subscriber whose filter matches.
+func (r *Router) Poll(b *Bot, dest chan Update, stop chan struct{}) {
+	cap := r.Capacity
+	if cap < 1 {
+		cap = 1
+	}
+
+	upstream := make(chan Update, cap)
+	stop2 := make(chan struct{})
+	go r.Poller.Poll(b, upstream, stop2)
+
+	for {
+		select {
+		case <-stop:
+			close(stop2)
+			return
+		case upd := <-upstream:
+			dest <- upd
+
+			r.mu.Lock()
+			for _, sub := range r.subs {
+				if !sub.filter(&upd) {
+					continue
+				}
+				select {
+				case sub.ch <- upd:
+				default:
+					// Backpressure is isolated per subscriber: a
+					// full subscriber buffer only drops for that
+					// subscriber, never blocks dest or the others.
+				}
+			}
+			r.mu.Unlock()
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,213 @@
+package telebot
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMemoryOffsetStore(t *testing.T) {
+	var s MemoryOffsetStore
+
+	if got, err := s.Load(); err != nil || got != 0 {
+		t.Fatalf("Load() = %d, %v; want 0, nil", got, err)
+	}
+
+	if err := s.Save(42); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if got, err := s.Load(); err != nil || got != 42 {
+		t.Fatalf("Load() = %d, %v; want 42, nil", got, err)
+	}
+}
+
+func TestFileOffsetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offset")
+	s := FileOffsetStore{Path: path}
+
+	// No file yet: Load should report 0, not an error.
+	if got, err := s.Load(); err != nil || got != 0 {
+		t.Fatalf("Load() on missing file = %d, %v; want 0, nil", got, err)
+	}
+
+	if err := s.Save(7); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if got, err := s.Load(); err != nil || got != 7 {
+		t.Fatalf("Load() = %d, %v; want 7, nil", got, err)
+	}
+
+	// A fresh store pointed at the same path picks up the saved value.
+	other := FileOffsetStore{Path: path}
+	if got, err := other.Load(); err != nil || got != 7 {
+		t.Fatalf("Load() from a new store = %d, %v; want 7, nil", got, err)
+	}
+}
+
+func TestWorkerFor(t *testing.T) {
+	const n = 4
+
+	// Same chat always lands on the same worker, positive or negative ID.
+	for _, id := range []int64{1, -1, 100, -100, 0} {
+		want := workerFor(id, n)
+		if got := workerFor(id, n); got != want {
+			t.Fatalf("workerFor(%d, %d) not stable: got %d and %d", id, n, want, got)
+		}
+		if want < 0 || want >= n {
+			t.Fatalf("workerFor(%d, %d) = %d; want in [0, %d)", id, n, want, n)
+		}
+	}
+}
+
+func TestChatID(t *testing.T) {
+	chat := &Chat{ID: 123}
+
+	tests := []struct {
+		name string
+		upd  Update
+		want int64
+	}{
+		{"message", Update{Message: &Message{Chat: chat}}, 123},
+		{"edited message", Update{EditedMessage: &Message{Chat: chat}}, 123},
+		{"channel post", Update{ChannelPost: &Message{Chat: chat}}, 123},
+		{"callback", Update{Callback: &Callback{Message: &Message{Chat: chat}}}, 123},
+		{"none", Update{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chatID(tt.upd); got != tt.want {
+				t.Errorf("chatID() = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeFanOutMetrics struct {
+	dropped    []int
+	lastDepths map[int]int
+}
+
+func (m *fakeFanOutMetrics) Dropped(worker int) {
+	m.dropped = append(m.dropped, worker)
+}
+
+func (m *fakeFanOutMetrics) QueueDepth(worker, depth int) {
+	if m.lastDepths == nil {
+		m.lastDepths = make(map[int]int)
+	}
+	m.lastDepths[worker] = depth
+}
+
+func TestFanOutPollerDispatch(t *testing.T) {
+	metrics := &fakeFanOutMetrics{}
+	p := &FanOutPoller{Metrics: metrics}
+
+	queues := []chan Update{make(chan Update, 1)}
+	upd := Update{Message: &Message{Chat: &Chat{ID: 1}}}
+
+	p.dispatch(queues, 1, upd)
+	if got := len(queues[0]); got != 1 {
+		t.Fatalf("after first dispatch, queue depth = %d; want 1", got)
+	}
+	if metrics.lastDepths[0] != 1 {
+		t.Fatalf("QueueDepth reported %d; want 1", metrics.lastDepths[0])
+	}
+
+	// The queue is now full; the next update for the same worker is dropped.
+	p.dispatch(queues, 1, upd)
+	if len(metrics.dropped) != 1 || metrics.dropped[0] != 0 {
+		t.Fatalf("dropped = %v; want [0]", metrics.dropped)
+	}
+	if got := len(queues[0]); got != 1 {
+		t.Fatalf("after dropped dispatch, queue depth = %d; want still 1", got)
+	}
+}
+
+func TestRouterSubscribeUnsubscribe(t *testing.T) {
+	r := NewRouter(nil)
+
+	ch, cancel := r.Subscribe(func(*Update) bool { return true })
+	if len(r.subs) != 1 {
+		t.Fatalf("subs = %d; want 1 after Subscribe", len(r.subs))
+	}
+
+	cancel()
+	if len(r.subs) != 0 {
+		t.Fatalf("subs = %d; want 0 after cancel", len(r.subs))
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after cancel")
+	}
+
+	// Cancel must be idempotent.
+	cancel()
+}
+
+func TestRouterSubscribeConcurrent(t *testing.T) {
+	r := NewRouter(nil)
+
+	const n = 20
+	cancels := make([]func(), n)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, cancel := r.Subscribe(func(*Update) bool { return false })
+			mu.Lock()
+			cancels[i] = cancel
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(r.subs) != n {
+		t.Fatalf("subs = %d; want %d", len(r.subs), n)
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	if len(r.subs) != 0 {
+		t.Fatalf("subs = %d; want 0 after all cancel", len(r.subs))
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	got := nextBackoff(time.Second, 2, 10*time.Second)
+	if got != 2*time.Second {
+		t.Fatalf("nextBackoff() = %v; want 2s", got)
+	}
+
+	// Growth is capped at max.
+	got = nextBackoff(8*time.Second, 2, 10*time.Second)
+	if got != 10*time.Second {
+		t.Fatalf("nextBackoff() = %v; want capped at 10s", got)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	plain := errors.New("getUpdates failed")
+	if got := backoffDelay(plain, 3*time.Second); got != 3*time.Second {
+		t.Fatalf("backoffDelay() = %v; want fallback 3s for a non-flood error", got)
+	}
+
+	// getUpdates returns FloodError by value, not by pointer.
+	flood := FloodError{RetryAfter: 5}
+	if got := backoffDelay(flood, 3*time.Second); got != 5*time.Second {
+		t.Fatalf("backoffDelay() = %v; want 5s from FloodError.RetryAfter", got)
+	}
+
+	wrapped := errors.Wrap(flood, "getUpdates() failed")
+	if got := backoffDelay(wrapped, 3*time.Second); got != 5*time.Second {
+		t.Fatalf("backoffDelay() = %v; want 5s even when FloodError is wrapped", got)
+	}
+}